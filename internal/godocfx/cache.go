@@ -0,0 +1,267 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v2"
+)
+
+// cacheMode is the value of the --cache flag.
+type cacheMode int
+
+const (
+	cacheOff cacheMode = iota
+	cacheOn
+	cacheRefresh
+)
+
+func parseCacheMode(s string) (cacheMode, error) {
+	switch s {
+	case "", "off":
+		return cacheOff, nil
+	case "on":
+		return cacheOn, nil
+	case "refresh":
+		return cacheRefresh, nil
+	default:
+		return cacheOff, fmt.Errorf("unknown --cache value %q, want one of on, off, refresh", s)
+	}
+}
+
+// binaryVersion identifies this build of godocfx for cache invalidation
+// purposes. It's a package var, rather than a constant, so tests can pin it.
+var binaryVersion = "dev"
+
+// pageCache caches rendered pages on disk, keyed by a fingerprint of each
+// package's inputs. This avoids re-rendering packages that haven't changed,
+// which matters for monorepos with hundreds of subpackages. It doesn't skip
+// loading: every package is still loaded and parsed by loadPackages before
+// the cache is consulted.
+type pageCache struct {
+	dir  string
+	mode cacheMode
+
+	mu          sync.Mutex
+	hits, total int
+}
+
+// newPageCache opens (creating if needed) the on-disk cache rooted at
+// $GOCACHE/godocfx, or os.UserCacheDir()/godocfx if GOCACHE isn't set.
+func newPageCache(mode cacheMode) (*pageCache, error) {
+	root := os.Getenv("GOCACHE")
+	if root == "" {
+		var err error
+		root, err = os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("os.UserCacheDir: %v", err)
+		}
+	}
+	dir := filepath.Join(root, "godocfx")
+	if mode != cacheOff {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("MkdirAll: %v", err)
+		}
+	}
+	return &pageCache{dir: dir, mode: mode}, nil
+}
+
+// summary returns a human-readable hit/miss summary, suitable for logging
+// once parse finishes.
+func (c *pageCache) summary() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("cache: %d/%d packages reused", c.hits, c.total)
+}
+
+// fingerprint hashes everything that affects a package's rendered page: the
+// contents of its .go files (including _test.go, since that's where most
+// Example functions live), its module's go.mod, the godocfx binary version,
+// the link resolver configuration, and the .go file contents of every
+// same-module package it imports, direct or transitive (so a changed
+// dependency invalidates the cache the same way a changed source file does,
+// the same as its rendered cross-package links would). This mirrors gopls'
+// package cache key, which covers all inputs into type-checking.
+func fingerprint(pi pkgInfo, resolverFingerprint string) (string, error) {
+	h := sha256.New()
+
+	files := append([]string{}, pi.files...)
+	sort.Strings(files)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("ReadFile(%q): %v", f, err)
+		}
+		fmt.Fprintf(h, "file:%s\n", f)
+		h.Write(b)
+	}
+
+	if pi.pkg.Module != nil && pi.pkg.Module.GoMod != "" {
+		b, err := ioutil.ReadFile(pi.pkg.Module.GoMod)
+		if err != nil {
+			return "", fmt.Errorf("ReadFile(%q): %v", pi.pkg.Module.GoMod, err)
+		}
+		h.Write(b)
+	}
+
+	if err := hashSameModuleDeps(h, pi.pkg); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(h, "binaryVersion:%s\n", binaryVersion)
+	fmt.Fprintf(h, "resolver:%s\n", resolverFingerprint)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashSameModuleDeps writes the contents of every .go file belonging to a
+// same-module (direct or transitive) dependency of pkg into h, sorted by
+// import path then file name. In this monorepo, all packages share one
+// go.mod, so pkg's own go.mod hash doesn't change when a dependency's
+// exported API does; this is what invalidates the cache in that case
+// instead.
+func hashSameModuleDeps(h io.Writer, pkg *packages.Package) error {
+	if pkg.Module == nil {
+		return nil
+	}
+
+	deps := map[string]*packages.Package{}
+	var collect func(p *packages.Package)
+	collect = func(p *packages.Package) {
+		for path, dep := range p.Imports {
+			if dep.Module == nil || dep.Module.Path != pkg.Module.Path {
+				continue
+			}
+			if _, ok := deps[path]; ok {
+				continue
+			}
+			deps[path] = dep
+			collect(dep)
+		}
+	}
+	collect(pkg)
+
+	paths := make([]string, 0, len(deps))
+	for path := range deps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		files := append([]string{}, deps[path].GoFiles...)
+		sort.Strings(files)
+		for _, f := range files {
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return fmt.Errorf("ReadFile(%q): %v", f, err)
+			}
+			fmt.Fprintf(h, "dep:%s:%s\n", path, f)
+			h.Write(b)
+		}
+	}
+	return nil
+}
+
+// get returns the cached page for the package with the given fingerprint, if
+// present and the cache isn't disabled or being refreshed.
+func (c *pageCache) get(fp string) (*page, bool) {
+	c.mu.Lock()
+	c.total++
+	c.mu.Unlock()
+
+	if c.mode != cacheOn {
+		return nil, false
+	}
+
+	b, err := ioutil.ReadFile(c.entryPath(fp))
+	if err != nil {
+		return nil, false
+	}
+	var p page
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+	return &p, true
+}
+
+// put stores pg in the cache under fp, guarded by a lock file so concurrent
+// godocfx invocations sharing a cache directory don't corrupt each other's
+// entries.
+func (c *pageCache) put(fp string, pg *page) error {
+	if c.mode == cacheOff {
+		return nil
+	}
+
+	unlock, err := c.lock(fp)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := yaml.Marshal(pg)
+	if err != nil {
+		return fmt.Errorf("yaml.Marshal: %v", err)
+	}
+	tmp := c.entryPath(fp) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("WriteFile: %v", err)
+	}
+	return os.Rename(tmp, c.entryPath(fp))
+}
+
+func (c *pageCache) entryPath(fp string) string {
+	return filepath.Join(c.dir, fp+".yaml")
+}
+
+// lock acquires a simple cross-process advisory lock for fp, so two
+// godocfx processes sharing a cache directory don't race on the same entry.
+// It's released by calling the returned func.
+func (c *pageCache) lock(fp string) (func(), error) {
+	lockPath := c.entryPath(fp) + ".lock"
+	const (
+		retryDelay = 50 * time.Millisecond
+		maxWait    = 10 * time.Second
+	)
+	deadline := time.Now().Add(maxWait)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("OpenFile: %v", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %q", lockPath)
+		}
+		time.Sleep(retryDelay)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v2"
+)
+
+// LinkResolver builds the URL used to link to a symbol in another package.
+//
+// pkg is the import path being linked to; links within the current package
+// are built by linker.toURL itself and never reach a LinkResolver. name is
+// the symbol name, or "" to link to the package index page. mod is the
+// module that owns pkg when the resolver handles pkg, and nil otherwise (or
+// when it's unknown, for example for the standard library).
+type LinkResolver interface {
+	toURL(pkg, name string, mod *packages.Module) string
+
+	// handles reports whether pkg matches a rule specific to this resolver,
+	// as opposed to falling through to a generic default. Callers use this
+	// to decide whether it's worth building an idToAnchor map for pkg, since
+	// generic fallbacks (like pkg.go.dev) don't support anchor lookups.
+	handles(pkg string) bool
+}
+
+// resolverRule is one entry of a LinkResolver config file, matching
+// import paths by prefix the same way golang.org/x/tools/internal/imports'
+// fix.go dispatches package resolution by prefix.
+type resolverRule struct {
+	// Prefix is the import path prefix this rule applies to.
+	Prefix string `yaml:"prefix"`
+
+	// Template is the URL template for a match. It may contain the
+	// placeholders {module}, {version}, {pkg}, and {anchor}.
+	Template string `yaml:"template"`
+
+	// Version controls how {version} is filled in:
+	//   "auto" or "" - same as "pinned".
+	//   "latest" - always use the literal string "latest".
+	//   "pinned" - use packages.Module.Version, falling back to "latest" if
+	//     the module has no version information.
+	Version string `yaml:"version"`
+}
+
+// resolverConfig is the on-disk format for configuring a configResolver,
+// typically named godocfx-links.yaml.
+type resolverConfig struct {
+	Rules []resolverRule `yaml:"rules"`
+}
+
+// loadResolverConfig reads and parses a LinkResolver config file. An empty
+// path returns defaultResolver().
+func loadResolverConfig(path string) (*configResolver, error) {
+	if path == "" {
+		return defaultResolver(), nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: %v", err)
+	}
+	var cfg resolverConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("yaml.Unmarshal: %v", err)
+	}
+	return &configResolver{rules: cfg.Rules}, nil
+}
+
+// configResolver is a LinkResolver that dispatches to the first matching
+// rule by import-path prefix, falling back to pkgGoDevResolver for anything
+// unmatched. This replaces the old hardcoded same-domain/pkg.go.dev split,
+// letting callers declare rules for additional doc-hosting domains.
+type configResolver struct {
+	rules []resolverRule
+}
+
+func (c *configResolver) toURL(pkg, name string, mod *packages.Module) string {
+	for _, r := range c.rules {
+		if r.matches(pkg) {
+			return r.resolve(pkg, name, mod)
+		}
+	}
+	return pkgGoDevResolver{}.toURL(pkg, name, mod)
+}
+
+// fingerprint returns a stable string representation of the resolver's
+// rules, for inclusion in the package cache fingerprint: changing the
+// resolver config must invalidate the cache the same way changing a source
+// file does.
+func (c *configResolver) fingerprint() string {
+	var b strings.Builder
+	for _, r := range c.rules {
+		fmt.Fprintf(&b, "%s|%s|%s\n", r.Prefix, r.Template, r.Version)
+	}
+	return b.String()
+}
+
+func (c *configResolver) handles(pkg string) bool {
+	for _, r := range c.rules {
+		if r.matches(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether r applies to pkg: its prefix matches, and pkg
+// isn't an internal package. Internal packages are never importable outside
+// their module, so they never have their own rendered page to link an
+// anchor into; fall through to pkgGoDevResolver the same way the old,
+// pre-configResolver toURL did.
+func (r resolverRule) matches(pkg string) bool {
+	if strings.Contains(pkg, "internal") {
+		return false
+	}
+	return r.Prefix == "" || strings.HasPrefix(pkg, r.Prefix)
+}
+
+// defaultResolver is the configResolver used when no config file is given to
+// parse, preserving godocfx's historical behavior of cross-linking
+// cloud.google.com/go packages to the devsite reference docs at "latest".
+func defaultResolver() *configResolver {
+	return &configResolver{
+		rules: []resolverRule{
+			{
+				Prefix:   "cloud.google.com/go",
+				Template: "/go/docs/reference/{module}/{version}/{pkg}#{anchor}",
+				Version:  "latest",
+			},
+		},
+	}
+}
+
+func (r resolverRule) resolve(pkg, name string, mod *packages.Module) string {
+	version := "latest"
+	switch r.Version {
+	case "latest":
+		version = "latest"
+	case "auto", "pinned", "":
+		if mod != nil && mod.Version != "" {
+			version = mod.Version
+		}
+	}
+
+	modPath := pkg
+	pkgRemainder := ""
+	if mod != nil {
+		modPath = mod.Path
+		if pkg != mod.Path {
+			pkgRemainder = pkg[len(mod.Path)+1:] // +1 to skip slash.
+		}
+	}
+
+	url := r.Template
+	if name == "" {
+		// No anchor to link to (e.g. a package index page): drop the
+		// "#{anchor}" segment entirely rather than leaving a bare trailing
+		// "#", matching the old toURL's behavior for name == "".
+		url = strings.Replace(url, "#{anchor}", "", 1)
+	}
+	url = strings.ReplaceAll(url, "{module}", modPath)
+	url = strings.ReplaceAll(url, "{version}", version)
+	url = strings.ReplaceAll(url, "{pkg}", pkgRemainder)
+	url = strings.ReplaceAll(url, "{anchor}", name)
+	return url
+}
+
+// pkgGoDevResolver is the default LinkResolver, linking everything to
+// pkg.go.dev. It's used for any import path not matched by a configResolver
+// rule.
+type pkgGoDevResolver struct{}
+
+func (pkgGoDevResolver) toURL(pkg, name string, mod *packages.Module) string {
+	const baseURL = "https://pkg.go.dev"
+	if name == "" {
+		return fmt.Sprintf("%s/%s", baseURL, pkg)
+	}
+	return fmt.Sprintf("%s/%s#%s", baseURL, pkg, name)
+}
+
+func (pkgGoDevResolver) handles(pkg string) bool { return false }
@@ -0,0 +1,272 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"cloud.google.com/go/third_party/go/doc"
+)
+
+// severity is how serious a lint finding is. Only severityError findings
+// affect -set_exit_status.
+type severity int
+
+const (
+	severityWarning severity = iota
+	severityError
+)
+
+func (s severity) String() string {
+	if s == severityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// finding is a single lint issue, modeled on the vendored golint tool:
+// a category, a severity, a message, and the position it applies to.
+type finding struct {
+	Category string
+	Severity severity
+	Message  string
+	Pos      token.Position
+}
+
+func (f finding) String() string {
+	return fmt.Sprintf("%s: %s: [%s] %s", f.Pos, f.Severity, f.Category, f.Message)
+}
+
+// hasErrors reports whether any finding is severityError, for -set_exit_status.
+func hasErrors(findings []finding) bool {
+	for _, f := range findings {
+		if f.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+// runLint loads the packages matching glob via loadForRender (the same
+// loading path parse uses) and runs lint against them. It's the entry point
+// for the lint subcommand in main.
+func runLint(glob, workingDir string, optionalExtraFiles, filter []string, linkResolverConfig string) ([]finding, error) {
+	pkgInfos, resolver, extraFiles, module, err := loadForRender(glob, workingDir, optionalExtraFiles, filter, linkResolverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return lint(pkgInfos, resolver, extraFiles, module.Dir)
+}
+
+// lint reports API quality issues against the parsed doc tree: undocumented
+// exported identifiers, examples that don't name a real symbol, broken
+// cross-references, and README anchors pointing at nonexistent symbols.
+//
+// It's built to run after loadPackages, reusing pkgInfo.doc and the linker's
+// idToAnchor maps rather than re-loading packages.
+func lint(pkgInfos []pkgInfo, resolver LinkResolver, extraFiles []extraFile, moduleDir string) ([]finding, error) {
+	var findings []finding
+	anchors := newAnchorCache()
+
+	for _, pi := range pkgInfos {
+		findings = append(findings, lintUndocumented(pi)...)
+		findings = append(findings, lintExamples(pi)...)
+
+		_, link := buildPackagePage(pi, resolver, anchors)
+		findings = append(findings, lintBrokenRefs(pi, link)...)
+	}
+
+	readmeFindings, err := lintReadmeAnchors(pkgInfos, extraFiles, moduleDir)
+	if err != nil {
+		return nil, fmt.Errorf("lintReadmeAnchors: %v", err)
+	}
+	findings = append(findings, readmeFindings...)
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Pos.Filename != findings[j].Pos.Filename {
+			return findings[i].Pos.Filename < findings[j].Pos.Filename
+		}
+		return findings[i].Pos.Line < findings[j].Pos.Line
+	})
+
+	return findings, nil
+}
+
+// lintUndocumented reports exported identifiers with no doc comment.
+func lintUndocumented(pi pkgInfo) []finding {
+	var findings []finding
+
+	report := func(pos token.Pos, kind, name string) {
+		findings = append(findings, finding{
+			Category: "undocumented",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("exported %s %s has no doc comment", kind, name),
+			Pos:      pi.fset.Position(pos),
+		})
+	}
+
+	for _, c := range pi.doc.Consts {
+		if c.Doc == "" {
+			report(c.Decl.Pos(), "const", strings.Join(c.Names, ", "))
+		}
+	}
+	for _, v := range pi.doc.Vars {
+		if v.Doc == "" {
+			report(v.Decl.Pos(), "var", strings.Join(v.Names, ", "))
+		}
+	}
+	for _, fn := range pi.doc.Funcs {
+		if fn.Doc == "" && token.IsExported(fn.Name) {
+			report(fn.Decl.Pos(), "func", fn.Name)
+		}
+	}
+	for _, t := range pi.doc.Types {
+		if t.Doc == "" {
+			report(t.Decl.Pos(), "type", t.Name)
+		}
+		for _, fn := range t.Funcs {
+			if fn.Doc == "" && token.IsExported(fn.Name) {
+				report(fn.Decl.Pos(), "func", fn.Name)
+			}
+		}
+		for _, fn := range t.Methods {
+			if fn.Doc == "" && token.IsExported(fn.Name) {
+				report(fn.Decl.Pos(), "method", fmt.Sprintf("(%s) %s", fn.Recv, fn.Name))
+			}
+		}
+	}
+
+	return findings
+}
+
+// isDescriptorSuffix reports whether suffix is a free-form, lowercase-led
+// example descriptor (e.g. the "secondary" in Example_secondary), as opposed
+// to a suffix that's meant to name a method (e.g. the "Get" in
+// ExampleClient_Get). See https://pkg.go.dev/go/doc#Example.
+func isDescriptorSuffix(suffix string) bool {
+	r, _ := utf8.DecodeRuneInString(suffix)
+	return unicode.IsLower(r)
+}
+
+// lintExamples reports type examples whose suffix names neither a method on
+// that type nor a valid lowercase descriptor, which usually means the
+// example is meant for a method that was renamed or removed.
+//
+// Package-level examples (suffix "" or a lowercase descriptor like
+// Example_secondary) are never flagged here: go/doc already accepts any
+// suffix for those, so there's no "declared symbol" to check against.
+//
+// TODO: verify the example's body type-checks against pi.pkg's go/types
+// info, not just that its suffix resolves; that needs matching example ASTs
+// (re-parsed by doc.NewFromFiles) back to pi.pkg.TypesInfo's AST.
+func lintExamples(pi pkgInfo) []finding {
+	var findings []finding
+
+	for _, t := range pi.doc.Types {
+		methods := map[string]bool{}
+		for _, fn := range t.Methods {
+			methods[fn.Name] = true
+		}
+		for _, fn := range t.Funcs {
+			methods[fn.Name] = true
+		}
+
+		for _, ex := range t.Examples {
+			if ex.Suffix == "" || isDescriptorSuffix(ex.Suffix) || methods[ex.Suffix] {
+				continue
+			}
+			findings = append(findings, finding{
+				Category: "broken-example",
+				Severity: severityError,
+				Message:  fmt.Sprintf("example suffix %q doesn't name a method on %s", ex.Suffix, t.Name),
+				Pos:      pi.fset.Position(ex.Code.Pos()),
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintBrokenRefs reports doc comment cross-references that linker.linkify
+// couldn't resolve, via the linker built for this package while rendering
+// its page.
+//
+// linkify only sees the symbol text, not its position, so findings here are
+// attributed to the package as a whole rather than a specific line.
+func lintBrokenRefs(pi pkgInfo, link *linker) []finding {
+	var findings []finding
+	for _, sym := range link.Unresolved() {
+		findings = append(findings, finding{
+			Category: "broken-ref",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("don't know how to link %q", sym),
+			Pos:      token.Position{Filename: pi.doc.ImportPath},
+		})
+	}
+	return findings
+}
+
+// readmeAnchorRegexp matches Markdown links to in-page anchors, e.g.
+// "[Foo](#Foo)".
+var readmeAnchorRegexp = regexp.MustCompile(`\]\(#([^)]+)\)`)
+
+// lintReadmeAnchors reports README anchors that don't point at a symbol
+// that actually exists in the module's root package.
+func lintReadmeAnchors(pkgInfos []pkgInfo, extraFiles []extraFile, moduleDir string) ([]finding, error) {
+	var rootDoc *doc.Package
+	for _, pi := range pkgInfos {
+		if rootDoc == nil || len(pi.doc.ImportPath) < len(rootDoc.ImportPath) {
+			rootDoc = pi.doc
+		}
+	}
+	if rootDoc == nil {
+		return nil, nil
+	}
+	idToAnchor := buildIDToAnchor(rootDoc)
+
+	var findings []finding
+	for _, ef := range extraFiles {
+		path := filepath.Join(moduleDir, ef.srcRelativePath)
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("ReadFile(%q): %v", path, err)
+		}
+		for _, m := range readmeAnchorRegexp.FindAllStringSubmatch(string(b), -1) {
+			anchor := m[1]
+			if _, ok := idToAnchor[anchor]; ok {
+				continue
+			}
+			findings = append(findings, finding{
+				Category: "readme-anchor",
+				Severity: severityError,
+				Message:  fmt.Sprintf("%s links to #%s, which isn't an exported symbol", ef.srcRelativePath, anchor),
+				Pos:      token.Position{Filename: path},
+			})
+		}
+	}
+	return findings, nil
+}
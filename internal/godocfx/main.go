@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build go1.15
+
+// godocfx generates reference documentation pages for a Go module.
+//
+// Usage:
+//
+//	godocfx [flags]
+//	godocfx lint [flags]
+//
+// The lint subcommand runs the exported-API quality checks in lint.go
+// instead of rendering pages; see its -set_exit_status flag for wiring it
+// into CI.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	if len(args) > 0 && args[0] == "lint" {
+		return runLintCmd(args[1:])
+	}
+	return runRender(args)
+}
+
+// runRender is the default command: render every package matched by --glob
+// into --out, in the format selected by --format.
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("godocfx", flag.ExitOnError)
+	glob := fs.String("glob", "", "glob pattern of packages to document, passed to packages.Load (required)")
+	workingDir := fs.String("work-dir", "", "directory to run the go command from")
+	out := fs.String("out", "", "directory to write the rendered pages to (required)")
+	extraFiles := fs.String("extra-files", "", "comma-separated list of extra files, relative to the module root, to include (e.g. README.md)")
+	filter := fs.String("filter", "", "comma-separated list of import path prefixes to exclude")
+	links := fs.String("links", "", "path to a LinkResolver config file; \"\" uses the default resolver")
+	cache := fs.String("cache", "off", "package cache mode: off, on, or refresh")
+	format := fs.String("format", "docfx", "output format: docfx, json, or openapi")
+	fs.Parse(args)
+
+	if *glob == "" || *out == "" {
+		return fmt.Errorf("--glob and --out are required")
+	}
+
+	cacheMode, err := parseCacheMode(*cache)
+	if err != nil {
+		return err
+	}
+	renderer, err := rendererForFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	res, err := parse(*glob, *workingDir, splitNonEmpty(*extraFiles), splitNonEmpty(*filter), *links, cacheMode)
+	if err != nil {
+		return fmt.Errorf("parse: %v", err)
+	}
+
+	if err := writePages(res, *out, renderer); err != nil {
+		return fmt.Errorf("writePages: %v", err)
+	}
+	return nil
+}
+
+// runLintCmd is the "lint" subcommand: report exported-API quality findings
+// for every package matched by --glob, without rendering anything.
+func runLintCmd(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	glob := fs.String("glob", "", "glob pattern of packages to lint, passed to packages.Load (required)")
+	workingDir := fs.String("work-dir", "", "directory to run the go command from")
+	extraFiles := fs.String("extra-files", "", "comma-separated list of extra files, relative to the module root, to check (e.g. README.md)")
+	filter := fs.String("filter", "", "comma-separated list of import path prefixes to exclude")
+	links := fs.String("links", "", "path to a LinkResolver config file; \"\" uses the default resolver")
+	setExitStatus := fs.Bool("set_exit_status", false, "exit with a non-zero status if any finding is severityError")
+	fs.Parse(args)
+
+	if *glob == "" {
+		return fmt.Errorf("--glob is required")
+	}
+
+	findings, err := runLint(*glob, *workingDir, splitNonEmpty(*extraFiles), splitNonEmpty(*filter), *links)
+	if err != nil {
+		return fmt.Errorf("runLint: %v", err)
+	}
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if *setExitStatus && hasErrors(findings) {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// splitNonEmpty splits s on commas, dropping empty elements, so an unset
+// flag produces a nil slice rather than []string{""}.
+func splitNonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
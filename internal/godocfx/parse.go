@@ -34,9 +34,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	goldmarkcodeblock "cloud.google.com/go/internal/godocfx/goldmark-codeblock"
 	"cloud.google.com/go/third_party/go/doc"
@@ -74,27 +76,27 @@ type child string
 
 // syntax represents syntax.
 type syntax struct {
-	Content string `yaml:"content,omitempty"`
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
 }
 
 type example struct {
-	Content string `yaml:"content,omitempty"`
-	Name    string `yaml:"name,omitempty"`
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
+	Name    string `yaml:"name,omitempty" json:"name,omitempty"`
 }
 
 // item represents a DocFX item.
 type item struct {
-	UID      string    `yaml:"uid"`
-	Name     string    `yaml:"name,omitempty"`
-	ID       string    `yaml:"id,omitempty"`
-	Summary  string    `yaml:"summary,omitempty"`
-	Parent   string    `yaml:"parent,omitempty"`
-	Type     string    `yaml:"type,omitempty"`
-	Langs    []string  `yaml:"langs,omitempty"`
-	Syntax   syntax    `yaml:"syntax,omitempty"`
-	Examples []example `yaml:"codeexamples,omitempty"`
-	Children []child   `yaml:"children,omitempty"`
-	AltLink  string    `yaml:"alt_link,omitempty"`
+	UID      string    `yaml:"uid" json:"uid"`
+	Name     string    `yaml:"name,omitempty" json:"name,omitempty"`
+	ID       string    `yaml:"id,omitempty" json:"id,omitempty"`
+	Summary  string    `yaml:"summary,omitempty" json:"summary,omitempty"`
+	Parent   string    `yaml:"parent,omitempty" json:"parent,omitempty"`
+	Type     string    `yaml:"type,omitempty" json:"type,omitempty"`
+	Langs    []string  `yaml:"langs,omitempty" json:"langs,omitempty"`
+	Syntax   syntax    `yaml:"syntax,omitempty" json:"syntax,omitempty"`
+	Examples []example `yaml:"codeexamples,omitempty" json:"codeExamples,omitempty"`
+	Children []child   `yaml:"children,omitempty" json:"children,omitempty"`
+	AltLink  string    `yaml:"alt_link,omitempty" json:"altLink,omitempty"`
 }
 
 func (p *page) addItem(i *item) {
@@ -116,7 +118,10 @@ type result struct {
 	extraFiles []extraFile
 }
 
-// parse parses the directory into a map of import path -> page and a TOC.
+// loadForRender loads the packages matching glob and the resolver and extra
+// files parse needs to build a result, and lint needs to find findings. It's
+// factored out of parse so the lint subcommand can reuse the same loading
+// and extra-file-filtering logic without going through parse's page cache.
 //
 // glob is the path to parse, usually ending in `...`. glob is passed directly
 // to packages.Load as-is.
@@ -124,12 +129,18 @@ type result struct {
 // workingDir is the directory to use to run go commands.
 //
 // optionalExtraFiles is a list of paths relative to the module root to include.
-func parse(glob string, workingDir string, optionalExtraFiles []string, filter []string) (*result, error) {
-	pages := map[string]*page{}
+//
+// linkResolverConfig is the path to a LinkResolver config file, or "" to use
+// the default resolver (see defaultResolver).
+func loadForRender(glob, workingDir string, optionalExtraFiles, filter []string, linkResolverConfig string) ([]pkgInfo, LinkResolver, []extraFile, *packages.Module, error) {
+	resolver, err := loadResolverConfig(linkResolverConfig)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("loadResolverConfig: %v", err)
+	}
 
 	pkgInfos, err := loadPackages(glob, workingDir, filter)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
 	}
 	module := pkgInfos[0].pkg.Module
 
@@ -154,147 +165,220 @@ func parse(glob string, workingDir string, optionalExtraFiles []string, filter [
 		}
 	}
 
+	return pkgInfos, resolver, extraFiles, module, nil
+}
+
+// parse parses the directory into a map of import path -> page and a TOC.
+//
+// cacheModeFlag controls the on-disk package cache; see --cache in main.
+func parse(glob string, workingDir string, optionalExtraFiles []string, filter []string, linkResolverConfig string, cacheModeFlag cacheMode) (*result, error) {
+	pages := map[string]*page{}
+
+	pkgInfos, resolver, extraFiles, module, err := loadForRender(glob, workingDir, optionalExtraFiles, filter, linkResolverConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	toc := buildTOC(module.Path, pkgInfos, extraFiles)
 
-	// Once the files are grouped by package, process each package
-	// independently.
+	cache, err := newPageCache(cacheModeFlag)
+	if err != nil {
+		return nil, fmt.Errorf("newPageCache: %v", err)
+	}
+	resolverFP := ""
+	if cr, ok := resolver.(*configResolver); ok {
+		resolverFP = cr.fingerprint()
+	}
+	anchors := newAnchorCache()
+
+	// Packages are independent once loadPackages returns, so fan the
+	// remaining work (linker construction, pkgsite.PrintType, example
+	// formatting, and goldmark conversion) out over a worker pool. Only the
+	// shared pages map and error need a lock; everything else a goroutine
+	// touches is pkgInfo-local or its own cache with internal locking.
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
 	for _, pi := range pkgInfos {
-		link := newLinker(pi)
-		topLevelDecls := pkgsite.TopLevelDecls(pi.doc)
-		pkgItem := &item{
-			UID:      pi.doc.ImportPath,
-			Name:     pi.doc.ImportPath,
-			ID:       pi.doc.Name,
-			Summary:  toHTML(pi.doc.Doc),
+		pi := pi
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fail := func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+
+			// Skip fingerprinting entirely when the cache is off: fingerprint
+			// hashes every file of the package plus every same-module
+			// dependency, transitively, so computing it just to immediately
+			// discard the result reintroduces the O(N^2) monorepo I/O this
+			// cache was built to avoid.
+			var (
+				fp      string
+				pkgPage *page
+				ok      bool
+			)
+			if cacheModeFlag != cacheOff {
+				var err error
+				fp, err = fingerprint(pi, resolverFP)
+				if err != nil {
+					fail(fmt.Errorf("fingerprint: %v", err))
+					return
+				}
+				pkgPage, ok = cache.get(fp)
+			}
+			if !ok {
+				pkgPage, _ = buildPackagePage(pi, resolver, anchors)
+				if cacheModeFlag != cacheOff {
+					if err := cache.put(fp, pkgPage); err != nil {
+						fail(fmt.Errorf("cache.put: %v", err))
+						return
+					}
+				}
+			}
+
+			mu.Lock()
+			pages[pi.doc.ImportPath] = pkgPage
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if cacheModeFlag != cacheOff {
+		log.Print(cache.summary())
+	}
+
+	return &result{
+		pages:      pages,
+		toc:        toc,
+		module:     module,
+		extraFiles: extraFiles,
+	}, nil
+}
+
+// buildPackagePage converts a single package's parsed doc tree into its
+// DocFX page. It's safe to call concurrently for different packages, as long
+// as they share the same anchors cache. The returned linker is the one used
+// to build the page; the lint subcommand uses it to find broken
+// cross-references via linker.Unresolved.
+func buildPackagePage(pi pkgInfo, resolver LinkResolver, anchors *anchorCache) (*page, *linker) {
+	link := newLinker(pi, resolver, anchors)
+	topLevelDecls := pkgsite.TopLevelDecls(pi.doc)
+	pkgItem := &item{
+		UID:      pi.doc.ImportPath,
+		Name:     pi.doc.ImportPath,
+		ID:       pi.doc.Name,
+		Summary:  toHTML(pi.doc.Doc),
+		Langs:    onlyGo,
+		Type:     "package",
+		Examples: processExamples(pi.doc.Examples, pi.fset),
+		AltLink:  "https://pkg.go.dev/" + pi.doc.ImportPath,
+	}
+	pkgPage := &page{Items: []*item{pkgItem}}
+
+	for _, c := range pi.doc.Consts {
+		name := strings.Join(c.Names, ", ")
+		id := strings.Join(c.Names, ",")
+		uid := pi.doc.ImportPath + "." + id
+		pkgItem.addChild(child(uid))
+		pkgPage.addItem(&item{
+			UID:     uid,
+			Name:    name,
+			ID:      id,
+			Parent:  pi.doc.ImportPath,
+			Type:    "const",
+			Summary: c.Doc,
+			Langs:   onlyGo,
+			Syntax:  syntax{Content: pkgsite.PrintType(pi.fset, c.Decl, link.toURL, topLevelDecls)},
+		})
+	}
+	for _, v := range pi.doc.Vars {
+		name := strings.Join(v.Names, ", ")
+		id := strings.Join(v.Names, ",")
+		uid := pi.doc.ImportPath + "." + id
+		pkgItem.addChild(child(uid))
+		pkgPage.addItem(&item{
+			UID:     uid,
+			Name:    name,
+			ID:      id,
+			Parent:  pi.doc.ImportPath,
+			Type:    "variable",
+			Summary: v.Doc,
+			Langs:   onlyGo,
+			Syntax:  syntax{Content: pkgsite.PrintType(pi.fset, v.Decl, link.toURL, topLevelDecls)},
+		})
+	}
+	for _, t := range pi.doc.Types {
+		uid := pi.doc.ImportPath + "." + t.Name
+		pkgItem.addChild(child(uid))
+		typeItem := &item{
+			UID:      uid,
+			Name:     t.Name,
+			ID:       t.Name,
+			Parent:   pi.doc.ImportPath,
+			Type:     "type",
+			Summary:  t.Doc,
 			Langs:    onlyGo,
-			Type:     "package",
-			Examples: processExamples(pi.doc.Examples, pi.fset),
-			AltLink:  "https://pkg.go.dev/" + pi.doc.ImportPath,
+			Syntax:   syntax{Content: pkgsite.PrintType(pi.fset, t.Decl, link.toURL, topLevelDecls)},
+			Examples: processExamples(t.Examples, pi.fset),
 		}
-		pkgPage := &page{Items: []*item{pkgItem}}
-		pages[pi.doc.ImportPath] = pkgPage
-
-		for _, c := range pi.doc.Consts {
+		// Note: items are added as page.Children, rather than
+		// typeItem.Children, as a workaround for the DocFX template.
+		pkgPage.addItem(typeItem)
+		for _, c := range t.Consts {
 			name := strings.Join(c.Names, ", ")
 			id := strings.Join(c.Names, ",")
-			uid := pi.doc.ImportPath + "." + id
-			pkgItem.addChild(child(uid))
+			cUID := pi.doc.ImportPath + "." + id
+			pkgItem.addChild(child(cUID))
 			pkgPage.addItem(&item{
-				UID:     uid,
+				UID:     cUID,
 				Name:    name,
 				ID:      id,
-				Parent:  pi.doc.ImportPath,
+				Parent:  uid,
 				Type:    "const",
 				Summary: c.Doc,
 				Langs:   onlyGo,
 				Syntax:  syntax{Content: pkgsite.PrintType(pi.fset, c.Decl, link.toURL, topLevelDecls)},
 			})
 		}
-		for _, v := range pi.doc.Vars {
+		for _, v := range t.Vars {
 			name := strings.Join(v.Names, ", ")
 			id := strings.Join(v.Names, ",")
-			uid := pi.doc.ImportPath + "." + id
-			pkgItem.addChild(child(uid))
+			cUID := pi.doc.ImportPath + "." + id
+			pkgItem.addChild(child(cUID))
 			pkgPage.addItem(&item{
-				UID:     uid,
+				UID:     cUID,
 				Name:    name,
 				ID:      id,
-				Parent:  pi.doc.ImportPath,
+				Parent:  uid,
 				Type:    "variable",
 				Summary: v.Doc,
 				Langs:   onlyGo,
 				Syntax:  syntax{Content: pkgsite.PrintType(pi.fset, v.Decl, link.toURL, topLevelDecls)},
 			})
 		}
-		for _, t := range pi.doc.Types {
-			uid := pi.doc.ImportPath + "." + t.Name
-			pkgItem.addChild(child(uid))
-			typeItem := &item{
-				UID:      uid,
-				Name:     t.Name,
-				ID:       t.Name,
-				Parent:   pi.doc.ImportPath,
-				Type:     "type",
-				Summary:  t.Doc,
-				Langs:    onlyGo,
-				Syntax:   syntax{Content: pkgsite.PrintType(pi.fset, t.Decl, link.toURL, topLevelDecls)},
-				Examples: processExamples(t.Examples, pi.fset),
-			}
-			// Note: items are added as page.Children, rather than
-			// typeItem.Children, as a workaround for the DocFX template.
-			pkgPage.addItem(typeItem)
-			for _, c := range t.Consts {
-				name := strings.Join(c.Names, ", ")
-				id := strings.Join(c.Names, ",")
-				cUID := pi.doc.ImportPath + "." + id
-				pkgItem.addChild(child(cUID))
-				pkgPage.addItem(&item{
-					UID:     cUID,
-					Name:    name,
-					ID:      id,
-					Parent:  uid,
-					Type:    "const",
-					Summary: c.Doc,
-					Langs:   onlyGo,
-					Syntax:  syntax{Content: pkgsite.PrintType(pi.fset, c.Decl, link.toURL, topLevelDecls)},
-				})
-			}
-			for _, v := range t.Vars {
-				name := strings.Join(v.Names, ", ")
-				id := strings.Join(v.Names, ",")
-				cUID := pi.doc.ImportPath + "." + id
-				pkgItem.addChild(child(cUID))
-				pkgPage.addItem(&item{
-					UID:     cUID,
-					Name:    name,
-					ID:      id,
-					Parent:  uid,
-					Type:    "variable",
-					Summary: v.Doc,
-					Langs:   onlyGo,
-					Syntax:  syntax{Content: pkgsite.PrintType(pi.fset, v.Decl, link.toURL, topLevelDecls)},
-				})
-			}
 
-			for _, fn := range t.Funcs {
-				fnUID := uid + "." + fn.Name
-				pkgItem.addChild(child(fnUID))
-				pkgPage.addItem(&item{
-					UID:      fnUID,
-					Name:     fmt.Sprintf("func %s\n", fn.Name),
-					ID:       fn.Name,
-					Parent:   uid,
-					Type:     "function",
-					Summary:  fn.Doc,
-					Langs:    onlyGo,
-					Syntax:   syntax{Content: pkgsite.Synopsis(pi.fset, fn.Decl, link.linkify)},
-					Examples: processExamples(fn.Examples, pi.fset),
-				})
-			}
-			for _, fn := range t.Methods {
-				fnUID := uid + "." + fn.Name
-				pkgItem.addChild(child(fnUID))
-				pkgPage.addItem(&item{
-					UID:      fnUID,
-					Name:     fmt.Sprintf("func (%s) %s\n", fn.Recv, fn.Name),
-					ID:       fn.Name,
-					Parent:   uid,
-					Type:     "method",
-					Summary:  fn.Doc,
-					Langs:    onlyGo,
-					Syntax:   syntax{Content: pkgsite.Synopsis(pi.fset, fn.Decl, link.linkify)},
-					Examples: processExamples(fn.Examples, pi.fset),
-				})
-			}
-		}
-		for _, fn := range pi.doc.Funcs {
-			uid := pi.doc.ImportPath + "." + fn.Name
-			pkgItem.addChild(child(uid))
+		for _, fn := range t.Funcs {
+			fnUID := uid + "." + fn.Name
+			pkgItem.addChild(child(fnUID))
 			pkgPage.addItem(&item{
-				UID:      uid,
+				UID:      fnUID,
 				Name:     fmt.Sprintf("func %s\n", fn.Name),
 				ID:       fn.Name,
-				Parent:   pi.doc.ImportPath,
+				Parent:   uid,
 				Type:     "function",
 				Summary:  fn.Doc,
 				Langs:    onlyGo,
@@ -302,14 +386,39 @@ func parse(glob string, workingDir string, optionalExtraFiles []string, filter [
 				Examples: processExamples(fn.Examples, pi.fset),
 			})
 		}
+		for _, fn := range t.Methods {
+			fnUID := uid + "." + fn.Name
+			pkgItem.addChild(child(fnUID))
+			pkgPage.addItem(&item{
+				UID:      fnUID,
+				Name:     fmt.Sprintf("func (%s) %s\n", fn.Recv, fn.Name),
+				ID:       fn.Name,
+				Parent:   uid,
+				Type:     "method",
+				Summary:  fn.Doc,
+				Langs:    onlyGo,
+				Syntax:   syntax{Content: pkgsite.Synopsis(pi.fset, fn.Decl, link.linkify)},
+				Examples: processExamples(fn.Examples, pi.fset),
+			})
+		}
+	}
+	for _, fn := range pi.doc.Funcs {
+		uid := pi.doc.ImportPath + "." + fn.Name
+		pkgItem.addChild(child(uid))
+		pkgPage.addItem(&item{
+			UID:      uid,
+			Name:     fmt.Sprintf("func %s\n", fn.Name),
+			ID:       fn.Name,
+			Parent:   pi.doc.ImportPath,
+			Type:     "function",
+			Summary:  fn.Doc,
+			Langs:    onlyGo,
+			Syntax:   syntax{Content: pkgsite.Synopsis(pi.fset, fn.Decl, link.linkify)},
+			Examples: processExamples(fn.Examples, pi.fset),
+		})
 	}
 
-	return &result{
-		pages:      pages,
-		toc:        toc,
-		module:     module,
-		extraFiles: extraFiles,
-	}, nil
+	return pkgPage, link
 }
 
 type linker struct {
@@ -323,13 +432,22 @@ type linker struct {
 	idToAnchor map[string]map[string]string
 
 	// sameDomainModules is a map from package path to module for every imported
-	// package that should cross link on the same domain.
+	// package that the resolver has a specific rule for.
 	sameDomainModules map[string]*packages.Module
-}
 
-func newLinker(pi pkgInfo) *linker {
-	sameDomainPrefixes := []string{"cloud.google.com/go"}
+	// resolver builds the base URL for a cross-package link. See
+	// LinkResolver.
+	resolver LinkResolver
+
+	// unresolved collects the symbols linkify didn't know how to link,
+	// for the lint subcommand's broken cross-reference check. It's only
+	// ever appended to by the linker that owns it, so it's safe even when
+	// linkers for different packages run concurrently in the parse worker
+	// pool.
+	unresolved []string
+}
 
+func newLinker(pi pkgInfo, resolver LinkResolver, anchors *anchorCache) *linker {
 	imports := map[string]string{}
 	sameDomainModules := map[string]*packages.Module{}
 	idToAnchor := map[string]map[string]string{}
@@ -343,17 +461,62 @@ func newLinker(pi pkgInfo) *linker {
 
 		// TODO: Consider documenting internal packages so we don't have to link
 		// out.
-		if pkg.Module != nil && hasPrefix(pkg.PkgPath, sameDomainPrefixes) && !strings.Contains(pkg.PkgPath, "internal") {
+		if pkg.Module != nil && resolver.handles(pkg.PkgPath) && !strings.Contains(pkg.PkgPath, "internal") {
 			sameDomainModules[path] = pkg.Module
-
-			docPkg, _ := doc.NewFromFiles(pkg.Fset, pkg.Syntax, path)
-			idToAnchor[path] = buildIDToAnchor(docPkg)
+			idToAnchor[path] = anchors.idToAnchorFor(path, pkg)
 		}
 	}
 
 	idToAnchor[""] = buildIDToAnchor(pi.doc)
 
-	return &linker{imports: imports, idToAnchor: idToAnchor, sameDomainModules: sameDomainModules}
+	return &linker{imports: imports, idToAnchor: idToAnchor, sameDomainModules: sameDomainModules, resolver: resolver}
+}
+
+// anchorCache memoizes buildIDToAnchor across linkers, keyed by import path
+// and a hash of the package's file set. Without it, every linker re-parses
+// every same-domain dependency via doc.NewFromFiles, which is O(N^2) in a
+// monorepo; it's also shared across the parse worker pool goroutines, so
+// it's safe for concurrent use.
+type anchorCache struct {
+	mu      sync.Mutex
+	entries map[string]map[string]string
+}
+
+func newAnchorCache() *anchorCache {
+	return &anchorCache{entries: map[string]map[string]string{}}
+}
+
+// idToAnchorFor returns the idToAnchor map for pkg (imported as path),
+// building and caching it on first use.
+func (c *anchorCache) idToAnchorFor(path string, pkg *packages.Package) map[string]string {
+	key := fileSetKey(path, pkg)
+
+	// Hold the lock while building: doc.NewFromFiles may edit pkg.Syntax,
+	// which is shared by every linker that imports pkg.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.entries[key]; ok {
+		return m
+	}
+
+	docPkg, _ := doc.NewFromFiles(pkg.Fset, pkg.Syntax, path)
+	m := buildIDToAnchor(docPkg)
+	c.entries[key] = m
+
+	return m
+}
+
+// fileSetKey identifies a package's file set for anchorCache, so a changed
+// dependency (different file sizes) doesn't reuse a stale cache entry.
+func fileSetKey(path string, pkg *packages.Package) string {
+	var b strings.Builder
+	b.WriteString(path)
+	for _, f := range pkg.Syntax {
+		if tf := pkg.Fset.File(f.Pos()); tf != nil {
+			fmt.Fprintf(&b, "|%s:%d", tf.Name(), tf.Size())
+		}
+	}
+	return b.String()
 }
 
 // nonWordRegex is based on
@@ -441,6 +604,7 @@ func (l *linker) linkify(s string) string {
 	split := strings.Split(s, ".")
 	if len(split) != 2 {
 		// Don't know how to link this.
+		l.unresolved = append(l.unresolved, s)
 		return fmt.Sprintf("%s%s", prefix, s)
 	}
 
@@ -448,14 +612,19 @@ func (l *linker) linkify(s string) string {
 	pkgPath, ok := l.imports[pkg]
 	if !ok {
 		// Don't know how to link this.
+		l.unresolved = append(l.unresolved, s)
 		return fmt.Sprintf("%s%s", prefix, s)
 	}
 	name := split[1]
 	return fmt.Sprintf("%s%s.%s", prefix, href(l.toURL(pkgPath, ""), pkg), href(l.toURL(pkgPath, name), name))
 }
 
-// TODO: link to the right baseURL, with the right module name and version
-// pattern.
+// Unresolved returns the symbols linkify fell through to the
+// "don't know how to link this" branch for, in encounter order.
+func (l *linker) Unresolved() []string {
+	return l.unresolved
+}
+
 func (l *linker) toURL(pkg, name string) string {
 	if pkg == "" {
 		if anchor := l.idToAnchor[""][name]; anchor != "" {
@@ -463,23 +632,10 @@ func (l *linker) toURL(pkg, name string) string {
 		}
 		return fmt.Sprintf("#%s", name)
 	}
-	if mod, ok := l.sameDomainModules[pkg]; ok {
-		pkgRemainder := ""
-		if pkg != mod.Path {
-			pkgRemainder = pkg[len(mod.Path)+1:] // +1 to skip slash.
-		}
-		// Note: we always link to latest. One day, we'll link to mod.Version.
-		baseURL := fmt.Sprintf("/go/docs/reference/%v/latest/%v", mod.Path, pkgRemainder)
-		if anchor := l.idToAnchor[pkg][name]; anchor != "" {
-			return fmt.Sprintf("%s#%s", baseURL, anchor)
-		}
-		return baseURL
-	}
-	baseURL := "https://pkg.go.dev"
-	if name == "" {
-		return fmt.Sprintf("%s/%s", baseURL, pkg)
+	if anchor := l.idToAnchor[pkg][name]; anchor != "" {
+		name = anchor
 	}
-	return fmt.Sprintf("%s/%s#%s", baseURL, pkg, name)
+	return l.resolver.toURL(pkg, name, l.sameDomainModules[pkg])
 }
 
 func href(url, text string) string {
@@ -597,11 +753,16 @@ type pkgInfo struct {
 	fset *token.FileSet
 	// importRenames is a map from package path to local name or "".
 	importRenames map[string]string
+	// files is every file that went into pi.doc, including _test.go files
+	// contributing to the external test package (that's where most Example
+	// functions live). Unlike pkg.GoFiles, which is the non-test package
+	// only, this is what the fingerprint cache key needs to hash.
+	files []string
 }
 
 func loadPackages(glob, workingDir string, filter []string) ([]pkgInfo, error) {
 	config := &packages.Config{
-		Mode:  packages.NeedName | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedModule | packages.NeedImports | packages.NeedDeps,
+		Mode:  packages.NeedName | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedModule | packages.NeedImports | packages.NeedDeps | packages.NeedFiles,
 		Tests: true,
 		Dir:   workingDir,
 	}
@@ -717,6 +878,7 @@ func loadPackages(glob, workingDir string, filter []string) ([]pkgInfo, error) {
 			doc:           docPkg,
 			fset:          fset,
 			importRenames: imports,
+			files:         pkgFiles[pkgPath],
 		})
 	}
 
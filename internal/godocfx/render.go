@@ -0,0 +1,224 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Renderer converts a parsed result into the bytes for a single package page.
+//
+// Implementations are selected with the --format flag: docfx (the
+// historical default), json, or openapi.
+type Renderer interface {
+	// Render returns the serialized form of pkgPage, the page for a single
+	// package within res.
+	Render(res *result, importPath string, pkgPage *page) ([]byte, error)
+
+	// FileExt is the file extension to use for files written by Render,
+	// including the leading dot.
+	FileExt() string
+}
+
+// rendererForFormat returns the Renderer for the given --format value.
+func rendererForFormat(format string) (Renderer, error) {
+	switch format {
+	case "", "docfx":
+		return docfxRenderer{}, nil
+	case "json":
+		return jsonRenderer{}, nil
+	case "openapi":
+		return openapiRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want one of docfx, json, openapi", format)
+	}
+}
+
+// docfxRenderer renders a page as DocFX-flavored YAML. This is the
+// historical output format of godocfx.
+type docfxRenderer struct{}
+
+func (docfxRenderer) Render(res *result, importPath string, pkgPage *page) ([]byte, error) {
+	return yaml.Marshal(pkgPage)
+}
+
+func (docfxRenderer) FileExt() string { return ".yml" }
+
+// jsonRenderer renders a page as a stable, machine-readable JSON document.
+//
+// The shape mirrors the page/item trees used internally, with
+// cross-package UIDs preserved, so tools that don't want to parse
+// DocFX-specific YAML (search indexers, IDE integrations, static site
+// generators) can consume godocfx output directly. The table of contents is
+// not part of the JSON output; writePages writes it only for docfx.
+type jsonRenderer struct{}
+
+// jsonPage is the JSON-schema-friendly mirror of page. It's a distinct type,
+// rather than reusing page's yaml tags, so the JSON schema can evolve
+// independently of the DocFX output.
+type jsonPage struct {
+	Schema     string  `json:"$schema"`
+	Module     string  `json:"module"`
+	ImportPath string  `json:"importPath"`
+	Items      []*item `json:"items"`
+	References []*item `json:"references,omitempty"`
+}
+
+const jsonSchemaURI = "https://pkg.go.dev/cloud.google.com/go/internal/godocfx/schema/v1"
+
+func (jsonRenderer) Render(res *result, importPath string, pkgPage *page) ([]byte, error) {
+	// Sort copies, not pkgPage.Items/References themselves: those slices are
+	// shared with the cached page, and sorting in place would reorder them
+	// for every other reader of the same *page (including a later cache hit
+	// for this same package). jsonPage.Items/References are already built in
+	// a deterministic order by parse, so this is only a defensive measure
+	// against future callers that build pages from a map.
+	items := append([]*item{}, pkgPage.Items...)
+	refs := append([]*item{}, pkgPage.References...)
+	sort.Slice(items, func(i, j int) bool { return items[i].UID < items[j].UID })
+	sort.Slice(refs, func(i, j int) bool { return refs[i].UID < refs[j].UID })
+
+	jp := &jsonPage{
+		Schema:     jsonSchemaURI,
+		Module:     res.module.Path,
+		ImportPath: importPath,
+		Items:      items,
+		References: refs,
+	}
+	return json.MarshalIndent(jp, "", "  ")
+}
+
+func (jsonRenderer) FileExt() string { return ".json" }
+
+// openapiRenderer renders a page as an OpenAPI 3 fragment describing the
+// package's exported symbols as a set of schemas. It's not a full OpenAPI
+// document (godocfx has no notion of HTTP paths); it's meant to be merged
+// into a larger `components.schemas` section by downstream tooling, the way
+// swag/swaggo generate fragments from Go struct annotations.
+type openapiRenderer struct{}
+
+type openapiFragment struct {
+	OpenAPI    string            `json:"openapi"`
+	Info       openapiInfo       `json:"info"`
+	Components openapiComponents `json:"components"`
+}
+
+type openapiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openapiComponents struct {
+	Schemas map[string]openapiSchema `json:"schemas"`
+}
+
+type openapiSchema struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// openapiSchemaTypes maps a DocFX item.Type to the OpenAPI "type" keyword
+// used to describe it. item.Type values not present here (currently just
+// "package", which has no schema of its own) are skipped.
+var openapiSchemaTypes = map[string]string{
+	"type":     "object",
+	"const":    "string",
+	"variable": "string",
+	"function": "object",
+	"method":   "object",
+}
+
+func (openapiRenderer) Render(res *result, importPath string, pkgPage *page) ([]byte, error) {
+	schemas := map[string]openapiSchema{}
+	for _, it := range pkgPage.Items {
+		schemaType, ok := openapiSchemaTypes[it.Type]
+		if !ok {
+			continue
+		}
+		schemas[it.ID] = openapiSchema{
+			Type:        schemaType,
+			Description: describeItem(it),
+		}
+	}
+	version := "unversioned"
+	if res.module.Version != "" {
+		version = res.module.Version
+	}
+	frag := openapiFragment{
+		OpenAPI: "3.0.3",
+		Info: openapiInfo{
+			Title:   importPath,
+			Version: version,
+		},
+		Components: openapiComponents{Schemas: schemas},
+	}
+	return json.MarshalIndent(frag, "", "  ")
+}
+
+// describeItem builds an openapiSchema description from its doc comment and
+// declaration, so the fragment carries the same information the DocFX and
+// JSON renderers do instead of an empty placeholder.
+func describeItem(it *item) string {
+	if it.Summary != "" && it.Syntax.Content != "" {
+		return it.Summary + "\n\n" + it.Syntax.Content
+	}
+	if it.Summary != "" {
+		return it.Summary
+	}
+	return it.Syntax.Content
+}
+
+func (openapiRenderer) FileExt() string { return ".openapi.json" }
+
+// writePages renders every package page in res with r and writes each to its
+// own file under outDir, named after the package's import path with r's
+// FileExt. For docfxRenderer, it also writes res.toc as toc.yml, since that's
+// the only format DocFX's site generator reads a table of contents from.
+func writePages(res *result, outDir string, r Renderer) error {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("MkdirAll: %v", err)
+	}
+
+	for importPath, pkgPage := range res.pages {
+		b, err := r.Render(res, importPath, pkgPage)
+		if err != nil {
+			return fmt.Errorf("Render(%q): %v", importPath, err)
+		}
+		name := strings.ReplaceAll(importPath, "/", "_") + r.FileExt()
+		if err := ioutil.WriteFile(filepath.Join(outDir, name), b, 0o644); err != nil {
+			return fmt.Errorf("WriteFile(%q): %v", name, err)
+		}
+	}
+
+	if _, ok := r.(docfxRenderer); ok {
+		b, err := yaml.Marshal(res.toc)
+		if err != nil {
+			return fmt.Errorf("yaml.Marshal(toc): %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(outDir, "toc.yml"), b, 0o644); err != nil {
+			return fmt.Errorf("WriteFile(toc.yml): %v", err)
+		}
+	}
+
+	return nil
+}